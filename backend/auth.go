@@ -0,0 +1,285 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// jwtSigningKeyEnvVar is read as a fallback when TasteBuddyConfig carries no signing key
+const jwtSigningKeyEnvVar = "TASTE_BUDDY_JWT_SIGNING_KEY"
+
+const accessTokenDuration = 24 * time.Hour
+
+// errUserAlreadyExists is returned by AddUser when username or email is already taken
+var errUserAlreadyExists = errors.New("username or email already taken")
+
+// signingKey returns the JWT signing key, preferring TasteBuddyConfig over the
+// environment variable. Falls back to an insecure development default so a
+// freshly checked-out server still runs, but logs a warning every time it does
+func (app *TasteBuddyApp) signingKey() []byte {
+	if app.config != nil && app.config.JWTSigningKey != "" {
+		return []byte(app.config.JWTSigningKey)
+	}
+	if key := os.Getenv(jwtSigningKeyEnvVar); key != "" {
+		return []byte(key)
+	}
+	app.LogWarning("signingKey", "No JWT signing key configured, falling back to an insecure development default")
+	return []byte("taste-buddy-dev-secret")
+}
+
+// User is a struct for a registered user
+type User struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Username     string             `json:"username" bson:"username" binding:"required"`
+	Email        string             `json:"email" bson:"email" binding:"required"`
+	PasswordHash string             `json:"-" bson:"passwordHash"`
+	CreatedAt    time.Time          `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+}
+
+// tasteBuddyClaims are the JWT claims issued for an authenticated user
+type tasteBuddyClaims struct {
+	UserID primitive.ObjectID `json:"userId"`
+	jwt.RegisteredClaims
+}
+
+// AuthMiddleware reads the bearer token from the Authorization header, validates it
+// and injects the userId into the TasteBuddyContext. If required is true, missing or
+// invalid tokens abort the request with an error
+func (server *TasteBuddyServer) AuthMiddleware(required bool) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		userId, err := server.userIdFromAuthHeader(context)
+		if err != nil {
+			if required {
+				server.LogError("AuthMiddleware", err)
+				UnauthorizedError(context)
+				context.Abort()
+				return
+			}
+			context.Next()
+			return
+		}
+
+		tasteBuddyContextFrom(context).UserID = userId
+		context.Next()
+	}
+}
+
+// userIdFromAuthHeader extracts and validates the bearer token from the request,
+// returning the userId encoded in its claims
+func (server *TasteBuddyServer) userIdFromAuthHeader(context *gin.Context) (primitive.ObjectID, error) {
+	header := context.GetHeader("Authorization")
+	if header == "" || !strings.HasPrefix(header, "Bearer ") {
+		return primitive.NilObjectID, errors.New("missing bearer token")
+	}
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+
+	return server.ValidateToken(tokenString)
+}
+
+// GetUserIdFromContext returns the userId injected by AuthMiddleware, if any
+func GetUserIdFromContext(context *gin.Context) (primitive.ObjectID, bool) {
+	value, ok := context.Get(tasteBuddyContextKey)
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	tasteBuddyContext, ok := value.(*TasteBuddyContext)
+	if !ok || tasteBuddyContext.UserID.IsZero() {
+		return primitive.NilObjectID, false
+	}
+	return tasteBuddyContext.UserID, true
+}
+
+// GenerateToken issues a signed access token for userId, valid for accessTokenDuration
+func (app *TasteBuddyApp) GenerateToken(userId primitive.ObjectID) (string, time.Time, error) {
+	expiresAt := time.Now().Add(accessTokenDuration)
+	claims := tasteBuddyClaims{
+		UserID: userId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(app.signingKey())
+	if err != nil {
+		return "", time.Time{}, app.LogError("GenerateToken", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// ValidateToken parses and validates tokenString, returning the userId from its claims
+func (server *TasteBuddyServer) ValidateToken(tokenString string) (primitive.ObjectID, error) {
+	claims := &tasteBuddyClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return server.signingKey(), nil
+	})
+	if err != nil {
+		return primitive.NilObjectID, server.LogError("ValidateToken", err)
+	}
+	if !token.Valid {
+		return primitive.NilObjectID, server.LogError("ValidateToken", errors.New("invalid token"))
+	}
+	return claims.UserID, nil
+}
+
+// HandleRegister gets called by router
+// Creates a new user with a bcrypt-hashed password and returns an access token
+func (server *TasteBuddyServer) HandleRegister(context *gin.Context) {
+	server.LogContextHandle(context, "HandleRegister", "Trying to register user")
+
+	var registerRequest struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := context.BindJSON(&registerRequest); err != nil {
+		server.LogError("HandleRegister", err)
+		BadRequestError(context, "Invalid registration request")
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(registerRequest.Password), bcrypt.DefaultCost)
+	if err != nil {
+		server.LogError("HandleRegister", err)
+		ServerError(context, true)
+		return
+	}
+
+	newUser := User{
+		Username:     registerRequest.Username,
+		Email:        registerRequest.Email,
+		PasswordHash: string(passwordHash),
+		CreatedAt:    time.Now(),
+	}
+
+	userId, err := server.AddUser(newUser)
+	if err != nil {
+		server.LogError("HandleRegister", err)
+		if errors.Is(err, errUserAlreadyExists) {
+			BadRequestError(context, "Username or email already taken")
+			return
+		}
+		ServerError(context, true)
+		return
+	}
+
+	server.respondWithToken(context, userId)
+}
+
+// HandleLogin gets called by router
+// Verifies the given credentials and returns a fresh access token
+func (server *TasteBuddyServer) HandleLogin(context *gin.Context) {
+	server.LogContextHandle(context, "HandleLogin", "Trying to log in user")
+
+	var loginRequest struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := context.BindJSON(&loginRequest); err != nil {
+		server.LogError("HandleLogin", err)
+		BadRequestError(context, "Invalid login request")
+		return
+	}
+
+	user, err := server.GetUserByUsername(loginRequest.Username)
+	if err != nil {
+		server.LogError("HandleLogin", err)
+		UnauthorizedError(context)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(loginRequest.Password)); err != nil {
+		server.LogWarning("HandleLogin", "Invalid password for user "+user.Username)
+		UnauthorizedError(context)
+		return
+	}
+
+	server.respondWithToken(context, user.ID)
+}
+
+// HandleLogout gets called by router
+// Access tokens are stateless, so logout is a client-side no-op acknowledged by the server
+func (server *TasteBuddyServer) HandleLogout(context *gin.Context) {
+	server.LogContextHandle(context, "HandleLogout", "Logging out user")
+	Success(context, "Logged out")
+}
+
+// respondWithToken issues a fresh access token for userId and writes it to the response
+func (server *TasteBuddyServer) respondWithToken(context *gin.Context, userId primitive.ObjectID) {
+	token, expiresAt, err := server.GenerateToken(userId)
+	if err != nil {
+		server.LogError("respondWithToken", err)
+		ServerError(context, true)
+		return
+	}
+
+	Success(context, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt,
+		"userId":    userId.Hex(),
+	})
+}
+
+// GetUsersCollection gets users collection from database
+func (app *TasteBuddyApp) GetUsersCollection() *mongo.Collection {
+	return app.client.Database("tastebuddy").Collection("users")
+}
+
+// AddUser adds a new user to the database and returns its id. Fails with
+// errUserAlreadyExists if the username or email is already taken
+func (app *TasteBuddyApp) AddUser(newUser User) (primitive.ObjectID, error) {
+	ctx := DefaultContext()
+
+	existing := app.GetUsersCollection().FindOne(ctx, bson.M{"$or": []bson.M{
+		{"username": newUser.Username},
+		{"email": newUser.Email},
+	}})
+	if existing.Err() == nil {
+		return primitive.NilObjectID, app.LogError("AddUser + "+newUser.Username, errUserAlreadyExists)
+	} else if existing.Err() != mongo.ErrNoDocuments {
+		return primitive.NilObjectID, app.LogError("AddUser + "+newUser.Username, existing.Err())
+	}
+
+	result, err := app.GetUsersCollection().InsertOne(ctx, newUser)
+	if err != nil {
+		// the FindOne check above is inherently racy: two concurrent registrations can
+		// both pass it and then collide on the unique index added in EnsureIndexes, so
+		// the loser's duplicate-key error still needs to map to errUserAlreadyExists
+		if mongo.IsDuplicateKeyError(err) {
+			return primitive.NilObjectID, app.LogError("AddUser + "+newUser.Username, errUserAlreadyExists)
+		}
+		return primitive.NilObjectID, app.LogError("AddUser + "+newUser.Username, err)
+	}
+
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetUserByUsername gets a user by its username from the database
+func (app *TasteBuddyApp) GetUserByUsername(username string) (User, error) {
+	ctx := DefaultContext()
+
+	result := app.GetUsersCollection().FindOne(ctx, bson.M{"username": username})
+	if result.Err() != nil {
+		return User{}, app.LogError("GetUserByUsername + "+username, result.Err())
+	}
+
+	var userFromDatabase User
+	if err := result.Decode(&userFromDatabase); err != nil {
+		return User{}, app.LogError("GetUserByUsername + "+username, err)
+	}
+
+	return userFromDatabase, nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DiscountProvider fetches discounts for a market from a single distributor.
+// Implementations are registered via RegisterDiscountProvider so that new
+// markets can be added without touching GetDiscountsFromAPI
+type DiscountProvider interface {
+	Name() string
+	FetchDiscounts(market Market) ([]Discount, error)
+	SupportsCity(city string) bool
+}
+
+// discountProviderRegistry holds all registered DiscountProviders, keyed by Name
+var discountProviderRegistry = make(map[string]DiscountProvider)
+
+// RegisterDiscountProvider registers provider under its Name, replacing any
+// provider previously registered under the same name
+func RegisterDiscountProvider(provider DiscountProvider) {
+	discountProviderRegistry[provider.Name()] = provider
+}
+
+// GetDiscountProviders returns all currently registered discount providers
+func GetDiscountProviders() []DiscountProvider {
+	providers := make([]DiscountProvider, 0, len(discountProviderRegistry))
+	for _, provider := range discountProviderRegistry {
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// RegisterDefaultDiscountProviders wires up the built-in discount providers plus
+// any generic HTTP+JSON providers configured via --config. Call once during
+// startup, before GoRoutineSaveDiscountsToDB is scheduled
+func (app *TasteBuddyApp) RegisterDefaultDiscountProviders() {
+	RegisterDiscountProvider(&edekaDiscountProvider{app})
+	RegisterDiscountProvider(&reweDiscountProvider{app})
+	RegisterDiscountProvider(&lidlDiscountProvider{app})
+
+	if app.config == nil {
+		return
+	}
+	for _, genericConfig := range app.config.GenericDiscountProviders {
+		RegisterDiscountProvider(NewGenericHTTPDiscountProvider(genericConfig))
+	}
+}
+
+// edekaDiscountProvider fetches discounts from Edeka's API
+type edekaDiscountProvider struct {
+	app *TasteBuddyApp
+}
+
+func (p *edekaDiscountProvider) Name() string { return "edeka" }
+
+func (p *edekaDiscountProvider) FetchDiscounts(market Market) ([]Discount, error) {
+	return p.app.GetEdekaDiscounts(market)
+}
+
+func (p *edekaDiscountProvider) SupportsCity(_ string) bool { return true }
+
+// reweDiscountProvider fetches discounts from Rewe's API
+type reweDiscountProvider struct {
+	app *TasteBuddyApp
+}
+
+func (p *reweDiscountProvider) Name() string { return "rewe" }
+
+func (p *reweDiscountProvider) FetchDiscounts(market Market) ([]Discount, error) {
+	return p.app.GetReweDiscounts(market)
+}
+
+func (p *reweDiscountProvider) SupportsCity(_ string) bool { return true }
+
+// lidlDiscountProvider is registered under "lidl"; it returns no discounts (see GetLidlDiscounts)
+type lidlDiscountProvider struct {
+	app *TasteBuddyApp
+}
+
+func (p *lidlDiscountProvider) Name() string { return "lidl" }
+
+func (p *lidlDiscountProvider) FetchDiscounts(market Market) ([]Discount, error) {
+	return p.app.GetLidlDiscounts(market)
+}
+
+func (p *lidlDiscountProvider) SupportsCity(_ string) bool { return true }
+
+// GetLidlDiscounts always returns an empty list and a nil error: it makes no
+// request to Lidl and ignores market
+func (app *TasteBuddyApp) GetLidlDiscounts(market Market) ([]Discount, error) {
+	return []Discount{}, nil
+}
+
+// GenericHTTPDiscountProviderConfig configures a distributor that exposes its
+// discounts as a plain JSON array over HTTP, so new markets can be added via
+// --config alone, without writing a Go implementation
+type GenericHTTPDiscountProviderConfig struct {
+	DistributorName string   `json:"distributorName"`
+	Endpoint        string   `json:"endpoint"`
+	Cities          []string `json:"cities"`
+}
+
+type genericHTTPDiscountProvider struct {
+	config GenericHTTPDiscountProviderConfig
+}
+
+// NewGenericHTTPDiscountProvider creates a DiscountProvider that fetches a JSON
+// array of Discount from config.Endpoint
+func NewGenericHTTPDiscountProvider(config GenericHTTPDiscountProviderConfig) *genericHTTPDiscountProvider {
+	return &genericHTTPDiscountProvider{config}
+}
+
+func (p *genericHTTPDiscountProvider) Name() string { return p.config.DistributorName }
+
+func (p *genericHTTPDiscountProvider) SupportsCity(city string) bool {
+	for _, supportedCity := range p.config.Cities {
+		if strings.EqualFold(supportedCity, city) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *genericHTTPDiscountProvider) FetchDiscounts(_ Market) ([]Discount, error) {
+	if p.config.Endpoint == "" {
+		return []Discount{}, errors.New("genericHTTPDiscountProvider " + p.config.DistributorName + ": no endpoint configured")
+	}
+
+	response, err := http.Get(p.config.Endpoint)
+	if err != nil {
+		return []Discount{}, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return []Discount{}, err
+	}
+
+	var discounts []Discount
+	if err := json.Unmarshal(body, &discounts); err != nil {
+		return []Discount{}, err
+	}
+	return discounts, nil
+}
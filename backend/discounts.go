@@ -43,12 +43,43 @@ func (server *TasteBuddyServer) HandleGetDiscountsByCity(context *gin.Context) {
 // Calls getDiscountsFromDB
 func (server *TasteBuddyServer) HandleGetAllDiscounts(context *gin.Context) {
 	server.Log("HandleGetAllDiscounts", "Get all discounts")
-	if discounts, err := server.GetAllDiscounts(); err != nil {
+
+	// no limit param: keep returning the full, unpaginated collection
+	limitParam := context.Query("limit")
+	if limitParam == "" {
+		if discounts, err := server.GetAllDiscounts(); err != nil {
+			server.LogError("HandleGetAllDiscounts", err)
+			ServerError(context, true)
+		} else {
+			Success(context, discounts)
+		}
+		return
+	}
+
+	limit, err := strconv.ParseInt(limitParam, 10, 64)
+	if err != nil || limit <= 0 {
+		BadRequestError(context, "Invalid limit")
+		return
+	}
+
+	cursor, err := DecodeCursor(context.Query("cursor"))
+	if err != nil {
+		BadRequestError(context, "Invalid cursor")
+		return
+	}
+
+	filter := DiscountFilter{Market: context.Query("market")}
+	if validAfter, ok := parseOptionalIntParam(context.Query("validAfter")); ok {
+		filter.ValidAfter = &validAfter
+	}
+
+	discounts, nextCursor, hasMore, err := server.GetDiscountsPage(filter, cursor, limit)
+	if err != nil {
 		server.LogError("HandleGetAllDiscounts", err)
 		ServerError(context, true)
-	} else {
-		Success(context, discounts)
+		return
 	}
+	Success(context, PagedResult{Data: discounts, NextCursor: nextCursor, HasMore: hasMore})
 }
 
 // GetDiscountsCollection gets discounts collection from database
@@ -99,15 +130,14 @@ func (app *TasteBuddyApp) GetDiscountsByCity(city string) ([]Discount, error) {
 	return discounts, nil
 }
 
-// GetDiscountsFromAPI gets all discounts for a market from the market's API
+// GetDiscountsFromAPI gets all discounts for a market from the registered
+// DiscountProvider for market.Distributor
 func (app *TasteBuddyApp) GetDiscountsFromAPI(market *Market) ([]Discount, error) {
-	switch market.Distributor {
-	case "edeka":
-		return app.GetEdekaDiscounts(*market)
-	case "rewe":
-		return app.GetReweDiscounts(*market)
+	provider, ok := discountProviderRegistry[market.Distributor]
+	if !ok {
+		return []Discount{}, nil
 	}
-	return []Discount{}, nil
+	return provider.FetchDiscounts(*market)
 }
 
 // GetAllDiscounts gets all discounts from database
@@ -124,6 +154,55 @@ func (app *TasteBuddyApp) GetAllDiscounts() ([]Discount, error) {
 	return discounts, nil
 }
 
+// DiscountFilter narrows a discount page down via server-side filters, pushed down
+// to MongoDB as bson predicates
+type DiscountFilter struct {
+	Market     string
+	ValidAfter *int
+}
+
+func (filter DiscountFilter) toBsonFilter() bson.M {
+	query := bson.M{}
+	if filter.Market != "" {
+		query["marketName"] = filter.Market
+	}
+	if filter.ValidAfter != nil {
+		query["validUntil"] = bson.M{"$gte": *filter.ValidAfter}
+	}
+	return query
+}
+
+// GetDiscountsPage gets a cursor-paginated, filtered page of discounts, sorted by
+// validUntil. Pass a nil cursor to start from the beginning
+func (app *TasteBuddyApp) GetDiscountsPage(filter DiscountFilter, cursor *Cursor, limit int64) ([]Discount, string, bool, error) {
+	ctx := DefaultContext()
+
+	query := bson.M{"$and": []bson.M{filter.toBsonFilter(), afterCursorFilter(cursor, "validUntil")}}
+
+	mongoCursor, err := app.GetDiscountsCollection().Find(ctx, query, paginationFindOptions("validUntil", limit))
+	if err != nil {
+		return nil, "", false, app.LogError("GetDiscountsPage", err)
+	}
+
+	var discounts []Discount
+	if err := mongoCursor.All(ctx, &discounts); err != nil {
+		return nil, "", false, app.LogError("GetDiscountsPage", err)
+	}
+
+	hasMore := int64(len(discounts)) > limit
+	if hasMore {
+		discounts = discounts[:limit]
+	}
+
+	var nextCursor string
+	if hasMore && len(discounts) > 0 {
+		last := discounts[len(discounts)-1]
+		nextCursor = EncodeCursor(last.ID, last.ValidUntil)
+	}
+
+	return discounts, nextCursor, hasMore, nil
+}
+
 // AddDiscounts adds discounts to database
 func (app *TasteBuddyApp) AddDiscounts(discounts []Discount) error {
 	app.Log("AddDiscounts", "Add "+strconv.Itoa(len(discounts))+" discounts to database")
@@ -162,18 +241,25 @@ func (app *TasteBuddyApp) GetDiscountsByCityFromAPI(city string) []Discount {
 	}
 }
 
-// GoRoutineSaveDiscountsToDB save discounts from different cities to the database
+// GoRoutineSaveDiscountsToDB save discounts from different cities to the database.
+// Cities are loaded from config and iterated against the DiscountProvider registry,
+// rather than a hard-coded city list
 // Is Goroutine
 func GoRoutineSaveDiscountsToDB(app *TasteBuddyApp) {
-	cities := []string{
-		"Konstanz",
-		"Berlin",
-		"Hamburg",
-		"Muenchen",
+	app.Log("GoRoutineSaveDiscountsToDB", "Start saving discounts to database")
+
+	// only scan cities that at least one registered provider supports
+	citiesToScan := make(map[string]bool)
+	for _, city := range app.GetConfiguredCities() {
+		for _, provider := range GetDiscountProviders() {
+			if provider.SupportsCity(city) {
+				citiesToScan[city] = true
+				break
+			}
+		}
 	}
 
-	app.Log("GoRoutineSaveDiscountsToDB", "Start saving discounts to database")
-	for _, city := range cities {
+	for city := range citiesToScan {
 		go app.SaveDiscountsFromAPI(city)
 	}
 }
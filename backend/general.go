@@ -1,17 +1,78 @@
 package main
 
 import (
+	"encoding/json"
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type TasteBuddyApp struct {
 	context *TasteBuddyContext
 	client  *TasteBuddyDatabase
+	config  *TasteBuddyConfig
+	logger  zerolog.Logger
+}
+
+// TasteBuddyConfig holds settings loaded from the --config file
+type TasteBuddyConfig struct {
+	Cities                   []string                            `json:"cities"`
+	GenericDiscountProviders []GenericHTTPDiscountProviderConfig `json:"genericDiscountProviders"`
+	JWTSigningKey            string                              `json:"jwtSigningKey"`
+}
+
+// SetConfig sets the config of the app
+func (app *TasteBuddyApp) SetConfig(config *TasteBuddyConfig) *TasteBuddyApp {
+	app.config = config
+	return app
+}
+
+// LoadConfig reads and parses a TasteBuddyConfig from the JSON file at path,
+// as passed via the --config flag
+func LoadConfig(path string) (*TasteBuddyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config TasteBuddyConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// GetConfiguredCities returns the cities to scan for discounts, falling back to a
+// small built-in default list if no config was loaded
+func (app *TasteBuddyApp) GetConfiguredCities() []string {
+	if app.config != nil && len(app.config.Cities) > 0 {
+		return app.config.Cities
+	}
+	return []string{"Konstanz", "Berlin", "Hamburg", "Muenchen"}
 }
 
 type TasteBuddyContext struct {
 	*gin.Context
+	UserID    primitive.ObjectID
+	RequestID string
+}
+
+// tasteBuddyContextKey is the gin.Context key the TasteBuddyContext is stored under
+const tasteBuddyContextKey = "tasteBuddyContext"
+
+// tasteBuddyContextFrom returns the TasteBuddyContext already stored on context by an
+// earlier middleware, or creates and stores a new one
+func tasteBuddyContextFrom(context *gin.Context) *TasteBuddyContext {
+	if value, ok := context.Get(tasteBuddyContextKey); ok {
+		if tbContext, ok := value.(*TasteBuddyContext); ok {
+			return tbContext
+		}
+	}
+	tbContext := &TasteBuddyContext{Context: context}
+	context.Set(tasteBuddyContextKey, tbContext)
+	return tbContext
 }
 
 type TasteBuddyDatabase struct {
@@ -28,6 +89,6 @@ func (app *TasteBuddyApp) SetDatabase(database *TasteBuddyDatabase) *TasteBuddyA
 }
 
 func (app *TasteBuddyApp) SetContext(context *gin.Context) *TasteBuddyApp {
-	app.context = &TasteBuddyContext{context}
+	app.context = &TasteBuddyContext{Context: context}
 	return app
-}
\ No newline at end of file
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// SetLogger configures the app's structured logger. logLevel is one of
+// debug|info|warn|error ("default" is treated as info). logFormat is either
+// "json" (newline-delimited JSON, the default) or "console" (human-readable)
+func (app *TasteBuddyApp) SetLogger(logLevel string, logFormat string) *TasteBuddyApp {
+	var writer io.Writer = os.Stdout
+	if logFormat == "console" {
+		writer = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	}
+
+	app.logger = zerolog.New(writer).Level(parseLogLevel(logLevel)).With().Timestamp().Logger()
+	return app
+}
+
+// parseLogLevel maps the --loglevel flag onto a zerolog.Level, defaulting to info
+func parseLogLevel(logLevel string) zerolog.Level {
+	switch strings.ToLower(logLevel) {
+	case "debug":
+		return zerolog.DebugLevel
+	case "warn":
+		return zerolog.WarnLevel
+	case "error":
+		return zerolog.ErrorLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// callerInfo returns "file:line" of the function skip frames above its own caller,
+// e.g. callerInfo(1) called from Log returns Log's caller
+func callerInfo(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	return filepath.Base(file) + ":" + strconv.Itoa(line)
+}
+
+// Log emits a structured info-level log line for handler and returns msg unchanged,
+// so it can be used inline in a return statement
+func (app *TasteBuddyApp) Log(handler string, msg string) string {
+	app.logger.Info().Str("caller", callerInfo(1)).Str("handler", handler).Msg(msg)
+	return msg
+}
+
+// LogWarning emits a structured warn-level log line for handler
+func (app *TasteBuddyApp) LogWarning(handler string, msg string) string {
+	app.logger.Warn().Str("caller", callerInfo(1)).Str("handler", handler).Msg(msg)
+	return msg
+}
+
+// LogError emits a structured error-level log line for handler and returns err
+// unchanged, so it can be used inline in a return statement
+func (app *TasteBuddyApp) LogError(handler string, err error) error {
+	if err == nil {
+		return nil
+	}
+	app.logger.Error().Str("caller", callerInfo(1)).Str("handler", handler).Msg(err.Error())
+	return err
+}
+
+// LogContextHandle emits a structured info-level log line for handler, enriched with
+// the request_id and user_id (if any) carried on context's TasteBuddyContext
+func (app *TasteBuddyApp) LogContextHandle(context *gin.Context, handler string, msg string) string {
+	tbContext := tasteBuddyContextFrom(context)
+	event := app.logger.Info().
+		Str("caller", callerInfo(1)).
+		Str("handler", handler).
+		Str("request_id", tbContext.RequestID)
+	if !tbContext.UserID.IsZero() {
+		event = event.Str("user_id", tbContext.UserID.Hex())
+	}
+	event.Msg(msg)
+	return msg
+}
+
+// RequestLoggingMiddleware assigns a correlation id to every request, stores it on
+// the TasteBuddyContext for LogContextHandle to pick up, and writes a single
+// structured access-log line once the response has been written
+func (server *TasteBuddyServer) RequestLoggingMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		tbContext := tasteBuddyContextFrom(context)
+		tbContext.RequestID = uuid.NewString()
+		context.Writer.Header().Set("X-Request-Id", tbContext.RequestID)
+
+		start := time.Now()
+		context.Next()
+
+		event := server.logger.Info().
+			Str("request_id", tbContext.RequestID).
+			Str("method", context.Request.Method).
+			Str("path", context.Request.URL.Path).
+			Int("status", context.Writer.Status()).
+			Int64("duration_ms", time.Since(start).Milliseconds())
+		if !tbContext.UserID.IsZero() {
+			event = event.Str("user_id", tbContext.UserID.Hex())
+		}
+		event.Msg("request handled")
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MealPlan is a struct for a user's weekly meal plan
+type MealPlan struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID  primitive.ObjectID `json:"userId" bson:"userId" binding:"required"`
+	Entries []MealPlanEntry    `json:"entries" bson:"entries" binding:"required"`
+}
+
+// MealPlanEntry is a single recipe planned for a date with a number of servings
+type MealPlanEntry struct {
+	RecipeID primitive.ObjectID `json:"recipeId" bson:"recipeId" binding:"required"`
+	Date     time.Time          `json:"date" bson:"date" binding:"required"`
+	Servings int                `json:"servings" bson:"servings" binding:"required"`
+}
+
+// ShoppingList is the aggregated items needed for all recipes in a meal plan
+type ShoppingList struct {
+	Items []ShoppingListItem `json:"items"`
+}
+
+// ShoppingListItem is a single aggregated item in a shopping list,
+// optionally annotated with the cheapest matching discount
+type ShoppingListItem struct {
+	Item         Item      `json:"item"`
+	Amount       int       `json:"amount"`
+	Unit         string    `json:"unit"`
+	BestDiscount *Discount `json:"bestDiscount,omitempty"`
+}
+
+// HandleAddMealPlan gets called by router
+// Calls AddOrUpdateMealPlan and handles the context
+func (server *TasteBuddyServer) HandleAddMealPlan(context *gin.Context) {
+	server.LogContextHandle(context, "HandleAddMealPlan", "Trying to add/update meal plan")
+
+	var newMealPlan MealPlan
+	if err := context.BindJSON(&newMealPlan); err != nil {
+		server.LogError("HandleAddMealPlan", err)
+		BadRequestError(context, "Invalid MealPlan")
+		return
+	}
+
+	userId, ok := GetUserIdFromContext(context)
+	if !ok {
+		UnauthorizedError(context)
+		return
+	}
+	newMealPlan.UserID = userId
+
+	mealPlanId, err := server.AddOrUpdateMealPlan(newMealPlan)
+	if err != nil {
+		server.LogError("HandleAddMealPlan", err)
+		ServerError(context, true)
+		return
+	}
+	server.LogContextHandle(context, "HandleAddMealPlan", "Added/Updated meal plan "+mealPlanId.Hex())
+	Success(context, "Saved meal plan "+mealPlanId.Hex())
+}
+
+// HandleGetMealPlanByUserId gets called by router
+// Calls GetMealPlanByUserId and handles the context
+func (server *TasteBuddyServer) HandleGetMealPlanByUserId(context *gin.Context) {
+	userId := context.Param("userId")
+
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		server.LogError("HandleGetMealPlanByUserId", err)
+		ServerError(context, true)
+		return
+	}
+
+	if authedUserId, ok := GetUserIdFromContext(context); !ok || authedUserId != objectId {
+		UnauthorizedError(context)
+		return
+	}
+
+	mealPlan, err := server.GetMealPlanByUserId(objectId)
+	if err != nil {
+		server.LogError("HandleGetMealPlanByUserId", err)
+		ServerError(context, true)
+		return
+	}
+	Success(context, mealPlan)
+}
+
+// HandleGetShoppingListByUserId gets called by router
+// Calls GetShoppingListByUserId and handles the context
+func (server *TasteBuddyServer) HandleGetShoppingListByUserId(context *gin.Context) {
+	userId := context.Param("userId")
+	city := context.Query("city")
+
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		server.LogError("HandleGetShoppingListByUserId", err)
+		ServerError(context, true)
+		return
+	}
+
+	if authedUserId, ok := GetUserIdFromContext(context); !ok || authedUserId != objectId {
+		UnauthorizedError(context)
+		return
+	}
+
+	shoppingList, err := server.GetShoppingListByUserId(objectId, city)
+	if err != nil {
+		server.LogError("HandleGetShoppingListByUserId", err)
+		ServerError(context, true)
+		return
+	}
+	Success(context, shoppingList)
+}
+
+// GetMealPlansCollection gets meal plans collection from database
+func (app *TasteBuddyApp) GetMealPlansCollection() *mongo.Collection {
+	return app.client.Database("tastebuddy").Collection("mealplans")
+}
+
+// GetMealPlanByUserId gets the meal plan for a user from the database
+func (app *TasteBuddyApp) GetMealPlanByUserId(userId primitive.ObjectID) (MealPlan, error) {
+	ctx := DefaultContext()
+
+	mealPlan := app.GetMealPlansCollection().FindOne(ctx, bson.M{"userId": userId})
+	if mealPlan.Err() != nil {
+		return MealPlan{}, app.LogError("GetMealPlanByUserId", mealPlan.Err())
+	}
+
+	var mealPlanFromDatabase MealPlan
+	if err := mealPlan.Decode(&mealPlanFromDatabase); err != nil {
+		return MealPlan{}, app.LogError("GetMealPlanByUserId", err)
+	}
+
+	return mealPlanFromDatabase, nil
+}
+
+// AddOrUpdateMealPlan adds a new meal plan to the database or updates the existing
+// meal plan for that user, and returns the id of the meal plan
+func (app *TasteBuddyApp) AddOrUpdateMealPlan(newMealPlan MealPlan) (primitive.ObjectID, error) {
+	ctx := DefaultContext()
+	var err error
+	var objectId primitive.ObjectID
+
+	// without an id, update the user's existing meal plan if there is one
+	if newMealPlan.ID.IsZero() {
+		var existing MealPlan
+		if findErr := app.GetMealPlansCollection().FindOne(ctx, bson.M{"userId": newMealPlan.UserID}).Decode(&existing); findErr == nil {
+			newMealPlan.ID = existing.ID
+		}
+	}
+
+	if newMealPlan.ID.IsZero() {
+		app.LogWarning("AddOrUpdateMealPlan + user "+newMealPlan.UserID.Hex(), "Add new meal plan to database")
+		var result *mongo.InsertOneResult
+		result, err = app.GetMealPlansCollection().InsertOne(ctx, newMealPlan)
+		if err == nil {
+			objectId = result.InsertedID.(primitive.ObjectID)
+		}
+	} else {
+		app.LogWarning("AddOrUpdateMealPlan + user "+newMealPlan.UserID.Hex(), "Update existing meal plan in database")
+		_, err = app.GetMealPlansCollection().UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: newMealPlan.ID}},
+			bson.D{{Key: "$set", Value: newMealPlan}})
+		objectId = newMealPlan.ID
+	}
+	if err != nil {
+		return objectId, app.LogError("AddOrUpdateMealPlan + user "+newMealPlan.UserID.Hex(), err)
+	}
+
+	return objectId, nil
+}
+
+// GetShoppingListByUserId builds the aggregated shopping list for a user's meal plan,
+// scaling each recipe's StepItem quantities by the planned servings, merging duplicate
+// items by Item.ID and normalized unit (an item listed in units that do not convert into
+// each other stays on separate lines), and annotating each line with the cheapest
+// matching discount in city
+func (app *TasteBuddyApp) GetShoppingListByUserId(userId primitive.ObjectID, city string) (ShoppingList, error) {
+	mealPlan, err := app.GetMealPlanByUserId(userId)
+	if err != nil {
+		return ShoppingList{}, app.LogError("GetShoppingListByUserId", err)
+	}
+
+	items, err := app.GetAllItems()
+	if err != nil {
+		return ShoppingList{}, app.LogError("GetShoppingListByUserId", err)
+	}
+
+	// aggregate StepItem quantities across all planned recipes, scaled by servings and
+	// normalized to a common unit per item, so e.g. "200 g" and "1 kg" sum correctly
+	type aggregateKey struct {
+		ItemID primitive.ObjectID
+		Unit   string
+	}
+	aggregated := make(map[aggregateKey]*ShoppingListItem)
+	for _, entry := range mealPlan.Entries {
+		recipe, err := app.GetRecipeById(entry.RecipeID)
+		if err != nil {
+			return ShoppingList{}, app.LogError("GetShoppingListByUserId + recipe "+entry.RecipeID.Hex(), err)
+		}
+		// GetRecipeById, unlike GetAllRecipes/GetRecipesPage, does not map item ids to
+		// items itself, so StepItem.Item would otherwise be a zero-value Item
+		recipe.MapItemIdsToItem(items)
+
+		for _, step := range recipe.Steps {
+			for _, stepItem := range step.Items {
+				amount, unit := normalizeUnit(stepItem.Amount*entry.Servings, stepItem.Unit)
+				key := aggregateKey{ItemID: stepItem.Item.ID, Unit: unit}
+				if existing, ok := aggregated[key]; ok {
+					existing.Amount += int(math.Round(amount))
+				} else {
+					aggregated[key] = &ShoppingListItem{
+						Item:   stepItem.Item,
+						Amount: int(math.Round(amount)),
+						Unit:   unit,
+					}
+				}
+			}
+		}
+	}
+
+	// cross-reference discounts for the user's city to find the cheapest match per item
+	discounts, err := app.GetDiscountsByCity(city)
+	if err != nil {
+		app.LogWarning("GetShoppingListByUserId", "No discounts found for city "+city)
+		discounts = []Discount{}
+	}
+
+	shoppingList := ShoppingList{}
+	for _, item := range aggregated {
+		item.BestDiscount = findCheapestDiscountForItem(item.Item, discounts)
+		shoppingList.Items = append(shoppingList.Items, *item)
+	}
+
+	return shoppingList, nil
+}
+
+// findCheapestDiscountForItem returns the cheapest discount whose title matches the item's
+// name, or nil if no matching discount was found
+func findCheapestDiscountForItem(item Item, discounts []Discount) *Discount {
+	var cheapest *Discount
+	var cheapestPrice float64
+	for i := range discounts {
+		discount := discounts[i]
+		if !strings.Contains(strings.ToLower(discount.Title), strings.ToLower(item.Name)) {
+			continue
+		}
+		price, err := parsePrice(discount.Price)
+		if err != nil {
+			continue
+		}
+		if cheapest == nil || price < cheapestPrice {
+			cheapest = &discount
+			cheapestPrice = price
+		}
+	}
+	return cheapest
+}
+
+// unitConversions maps a unit to the canonical base unit for its measurement family and
+// the factor that converts an amount in that unit into the base unit, so quantities given
+// in different but compatible units (e.g. "g" and "kg") can be aggregated meaningfully
+var unitConversions = map[string]struct {
+	Base   string
+	Factor float64
+}{
+	"g":  {"g", 1},
+	"kg": {"g", 1000},
+	"ml": {"ml", 1},
+	"l":  {"ml", 1000},
+}
+
+// normalizeUnit converts amount in unit to its canonical base unit. Units with no known
+// conversion (e.g. "piece") are returned unchanged
+func normalizeUnit(amount int, unit string) (float64, string) {
+	if conversion, ok := unitConversions[strings.ToLower(unit)]; ok {
+		return float64(amount) * conversion.Factor, conversion.Base
+	}
+	return float64(amount), unit
+}
+
+// priceNumberRegex captures the numeric portion of a price string such as "9,99 €"
+var priceNumberRegex = regexp.MustCompile(`[\d.,]+`)
+
+// parsePrice extracts the numeric value out of a price string like "9,99 €",
+// normalizing the European decimal comma to a dot
+func parsePrice(price string) (float64, error) {
+	number := priceNumberRegex.FindString(price)
+	if number == "" {
+		return 0, errors.New("no numeric price found in " + price)
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(number, ",", "."), 64)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Cursor is the opaque pagination cursor carried in the `cursor` query param,
+// base64-encoded as JSON{lastId, lastSortKey}
+type Cursor struct {
+	LastID      primitive.ObjectID `json:"lastId"`
+	LastSortKey interface{}        `json:"lastSortKey"`
+}
+
+// EncodeCursor base64-encodes a Cursor pointing at the last document of a page
+func EncodeCursor(lastId primitive.ObjectID, lastSortKey interface{}) string {
+	data, _ := json.Marshal(Cursor{LastID: lastId, LastSortKey: lastSortKey})
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor. An empty string is not
+// an error: it means "from the start"
+func DecodeCursor(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	data, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// PagedResult is the {data, nextCursor, hasMore} envelope returned by
+// cursor-paginated list endpoints
+type PagedResult struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+	HasMore    bool        `json:"hasMore"`
+}
+
+// afterCursorFilter builds the predicate that selects documents sorted strictly
+// after cursor on sortField (ascending), tie-broken by _id
+func afterCursorFilter(cursor *Cursor, sortField string) bson.M {
+	if cursor == nil {
+		return bson.M{}
+	}
+	return bson.M{"$or": []bson.M{
+		{sortField: bson.M{"$gt": cursor.LastSortKey}},
+		{sortField: cursor.LastSortKey, "_id": bson.M{"$gt": cursor.LastID}},
+	}}
+}
+
+// paginationFindOptions requests limit+1 documents (the extra document is used to
+// detect hasMore without a separate count query), sorted by sortField then _id
+func paginationFindOptions(sortField string, limit int64) *options.FindOptions {
+	return options.Find().
+		SetSort(bson.D{{Key: sortField, Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit + 1)
+}
+
+// splitCommaParam splits a comma-separated query param into trimmed, non-empty values
+func splitCommaParam(param string) []string {
+	if param == "" {
+		return nil
+	}
+	var values []string
+	for _, part := range strings.Split(param, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
+
+// parseOptionalIntParam parses an optional integer query param, returning ok=false
+// when the param was not set
+func parseOptionalIntParam(param string) (int, bool) {
+	if param == "" {
+		return 0, false
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// EnsureIndexes creates the compound indexes pagination and filtering rely on.
+// Safe to call on every startup: CreateMany is a no-op for indexes that already exist
+func (app *TasteBuddyApp) EnsureIndexes() error {
+	ctx := DefaultContext()
+
+	recipeIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+		{Keys: bson.D{{Key: "props.tags", Value: 1}}},
+	}
+	if _, err := app.GetRecipesCollection().Indexes().CreateMany(ctx, recipeIndexes); err != nil {
+		return app.LogError("EnsureIndexes + recipes", err)
+	}
+
+	discountIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "validUntil", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "marketName", Value: 1}}},
+		{Keys: bson.D{{Key: "internalMarketId", Value: 1}}},
+	}
+	if _, err := app.GetDiscountsCollection().Indexes().CreateMany(ctx, discountIndexes); err != nil {
+		return app.LogError("EnsureIndexes + discounts", err)
+	}
+
+	userIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := app.GetUsersCollection().Indexes().CreateMany(ctx, userIndexes); err != nil {
+		return app.LogError("EnsureIndexes + users", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDecodeCursorEmptyIsStart(t *testing.T) {
+	cursor, err := DecodeCursor("")
+	if err != nil {
+		t.Fatalf("DecodeCursor(\"\") returned unexpected error: %v", err)
+	}
+	if cursor != nil {
+		t.Errorf("DecodeCursor(\"\") = %v, want nil", cursor)
+	}
+}
+
+func TestEncodeDecodeCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	encoded := EncodeCursor(id, "some-sort-key")
+
+	cursor, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("DecodeCursor(%q) returned unexpected error: %v", encoded, err)
+	}
+	if cursor == nil {
+		t.Fatal("DecodeCursor() = nil, want a cursor")
+	}
+	if cursor.LastID != id {
+		t.Errorf("cursor.LastID = %v, want %v", cursor.LastID, id)
+	}
+	if cursor.LastSortKey != "some-sort-key" {
+		t.Errorf("cursor.LastSortKey = %v, want %v", cursor.LastSortKey, "some-sort-key")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	if _, err := DecodeCursor("not-valid-base64!!"); err == nil {
+		t.Error("DecodeCursor() with invalid input did not return an error")
+	}
+}
+
+func TestSplitCommaParam(t *testing.T) {
+	tests := []struct {
+		param string
+		want  []string
+	}{
+		{"", nil},
+		{"a,b,c", []string{"a", "b", "c"}},
+		{"a, b , c", []string{"a", "b", "c"}},
+		{",,", nil},
+	}
+
+	for _, test := range tests {
+		got := splitCommaParam(test.param)
+		if len(got) != len(test.want) {
+			t.Errorf("splitCommaParam(%q) = %v, want %v", test.param, got, test.want)
+			continue
+		}
+		for i := range test.want {
+			if got[i] != test.want[i] {
+				t.Errorf("splitCommaParam(%q)[%d] = %q, want %q", test.param, i, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseOptionalIntParam(t *testing.T) {
+	tests := []struct {
+		param  string
+		want   int
+		wantOk bool
+	}{
+		{"", 0, false},
+		{"42", 42, true},
+		{"not-a-number", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseOptionalIntParam(test.param)
+		if ok != test.wantOk || got != test.want {
+			t.Errorf("parseOptionalIntParam(%q) = (%v, %v), want (%v, %v)", test.param, got, ok, test.want, test.wantOk)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 
 type Recipe struct {
 	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	OwnerID     primitive.ObjectID `json:"ownerId,omitempty" bson:"ownerId,omitempty"`
 	Name        string             `json:"name" bson:"name" binding:"required"`
 	Author      string             `json:"author" bson:"author" binding:"required"`
 	Description string             `json:"description" bson:"description" binding:"required"`
@@ -69,22 +71,58 @@ type StepItem struct {
 }
 
 type Item struct {
-	ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Name   string             `json:"name" bson:"name" binding:"required"`
-	Type   string             `json:"type,omitempty" bson:"type,omitempty"`
-	ImgUrl string             `json:"imgUrl,omitempty" bson:"imgUrl,omitempty"`
+	ID            primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name          string               `json:"name" bson:"name" binding:"required"`
+	Type          string               `json:"type,omitempty" bson:"type,omitempty"`
+	ImgUrl        string               `json:"imgUrl,omitempty" bson:"imgUrl,omitempty"`
+	Substitutions []primitive.ObjectID `json:"substitutions,omitempty" bson:"-"`
 }
 
 // HandleGetAllRecipes gets called by router
 // Calls getRecipesFromDB and handles the context
 func (server *TasteBuddyServer) HandleGetAllRecipes(context *gin.Context) {
-	recipes, err := server.GetAllRecipes()
+	userId, _ := GetUserIdFromContext(context)
+
+	// no limit param: keep returning the full, unpaginated collection
+	limitParam := context.Query("limit")
+	if limitParam == "" {
+		recipes, err := server.GetAllRecipes(userId)
+		if err != nil {
+			server.LogError("HandleGetAllRecipes", err)
+			ServerError(context, true)
+			return
+		}
+		Success(context, recipes)
+		return
+	}
+
+	limit, err := strconv.ParseInt(limitParam, 10, 64)
+	if err != nil || limit <= 0 {
+		BadRequestError(context, "Invalid limit")
+		return
+	}
+
+	cursor, err := DecodeCursor(context.Query("cursor"))
+	if err != nil {
+		BadRequestError(context, "Invalid cursor")
+		return
+	}
+
+	filter := RecipeFilter{
+		Tags:   splitCommaParam(context.Query("tags")),
+		Author: context.Query("author"),
+	}
+	if maxDuration, ok := parseOptionalIntParam(context.Query("maxDuration")); ok {
+		filter.MaxDuration = &maxDuration
+	}
+
+	recipes, nextCursor, hasMore, err := server.GetRecipesPage(userId, filter, cursor, limit)
 	if err != nil {
 		server.LogError("HandleGetAllRecipes", err)
 		ServerError(context, true)
 		return
 	}
-	Success(context, recipes)
+	Success(context, PagedResult{Data: recipes, NextCursor: nextCursor, HasMore: hasMore})
 }
 
 func (server *TasteBuddyServer) HandleGetRecipeById(context *gin.Context) {
@@ -104,13 +142,21 @@ func (server *TasteBuddyServer) HandleGetRecipeById(context *gin.Context) {
 		ServerError(context, true)
 		return
 	}
+
+	// only the owner may view a private recipe
+	if userId, ok := GetUserIdFromContext(context); !recipe.OwnerID.IsZero() && (!ok || recipe.OwnerID != userId) {
+		UnauthorizedError(context)
+		return
+	}
+
 	Success(context, recipe)
 }
 
 // HandleGetRandomRecipe gets called by router
 // Calls getRecipesFromDB and selects a random recipe
 func (server *TasteBuddyServer) HandleGetRandomRecipe(context *gin.Context) {
-	recipes, err := server.GetAllRecipes()
+	userId, _ := GetUserIdFromContext(context)
+	recipes, err := server.GetAllRecipes(userId)
 	if err != nil {
 		server.LogError("HandleGetRandomRecipe", err)
 		ServerError(context, true)
@@ -141,6 +187,30 @@ func (server *TasteBuddyServer) HandleAddRecipe(context *gin.Context) {
 		return
 	}
 
+	userId, authed := GetUserIdFromContext(context)
+
+	// updating an existing recipe: only its owner may do so, same check as
+	// HandleDeleteRecipeById. Without it, anyone could overwrite another user's
+	// recipe (and reassign its ownership) just by posting its id
+	if !newRecipe.ID.IsZero() {
+		existingRecipe, err := server.GetRecipeById(newRecipe.ID)
+		if err != nil {
+			server.LogError("HandleAddRecipe", err)
+			ServerError(context, true)
+			return
+		}
+		if !existingRecipe.OwnerID.IsZero() && (!authed || existingRecipe.OwnerID != userId) {
+			UnauthorizedError(context)
+			return
+		}
+		// preserve the existing owner (or lack of one) instead of letting whoever
+		// edits a public recipe next silently privatize it under their account
+		newRecipe.OwnerID = existingRecipe.OwnerID
+	} else if authed {
+		// scope a newly created recipe to the authenticated user, if any
+		newRecipe.OwnerID = userId
+	}
+
 	var recipeId primitive.ObjectID
 	var err error
 	if recipeId, err = server.AddOrUpdateRecipe(newRecipe); err != nil {
@@ -166,6 +236,18 @@ func (server *TasteBuddyServer) HandleDeleteRecipeById(context *gin.Context) {
 		return
 	}
 
+	// only the owner may delete a private recipe
+	recipe, err := server.GetRecipeById(objectID)
+	if err != nil {
+		server.LogError("HandleDeleteRecipeById", err)
+		ServerError(context, true)
+		return
+	}
+	if userId, ok := GetUserIdFromContext(context); !recipe.OwnerID.IsZero() && (!ok || recipe.OwnerID != userId) {
+		UnauthorizedError(context)
+		return
+	}
+
 	// delete recipe
 	if _, err := server.DeleteRecipeById(objectID); err != nil {
 		server.LogError("HandleDeleteRecipeById", err)
@@ -197,12 +279,100 @@ func (app *TasteBuddyApp) GetRecipesCollection() *mongo.Collection {
 	return app.client.Database("tastebuddy").Collection("recipes")
 }
 
-// GetAllRecipes gets all recipes from database
-func (app *TasteBuddyApp) GetAllRecipes() ([]Recipe, error) {
+// GetAllRecipes gets all recipes from database that are public or owned by userId.
+// Pass primitive.NilObjectID to only get public recipes
+func (app *TasteBuddyApp) GetAllRecipes(userId primitive.ObjectID) ([]Recipe, error) {
+	// get all recipes from database that are not deleted and are either public
+	// (no owner) or owned by the requesting user
+	filter := bson.M{
+		"deleted": bson.M{"$ne": true},
+		"$or": []bson.M{
+			{"ownerId": bson.M{"$exists": false}},
+			{"ownerId": userId},
+		},
+	}
+	return app.getRecipesByFilter(filter)
+}
+
+// RecipeFilter narrows a recipe page down via server-side filters, pushed down to
+// MongoDB as bson predicates
+type RecipeFilter struct {
+	Tags        []string
+	MaxDuration *int
+	Author      string
+}
+
+func (filter RecipeFilter) toBsonFilter() bson.M {
+	query := bson.M{}
+	if len(filter.Tags) > 0 {
+		query["props.tags"] = bson.M{"$all": filter.Tags}
+	}
+	if filter.MaxDuration != nil {
+		query["props.duration"] = bson.M{"$lte": *filter.MaxDuration}
+	}
+	if filter.Author != "" {
+		query["author"] = filter.Author
+	}
+	return query
+}
+
+// GetRecipesPage gets a cursor-paginated, filtered page of recipes visible to userId,
+// sorted by name. Pass a nil cursor to start from the beginning
+func (app *TasteBuddyApp) GetRecipesPage(userId primitive.ObjectID, filter RecipeFilter, cursor *Cursor, limit int64) ([]Recipe, string, bool, error) {
+	ctx := DefaultContext()
+
+	ownerFilter := bson.M{
+		"deleted": bson.M{"$ne": true},
+		"$or": []bson.M{
+			{"ownerId": bson.M{"$exists": false}},
+			{"ownerId": userId},
+		},
+	}
+	query := bson.M{"$and": []bson.M{ownerFilter, filter.toBsonFilter(), afterCursorFilter(cursor, "name")}}
+
+	mongoCursor, err := app.GetRecipesCollection().Find(ctx, query, paginationFindOptions("name", limit))
+	if err != nil {
+		return nil, "", false, app.LogError("GetRecipesPage", err)
+	}
+
+	var recipesFromDatabase []Recipe
+	if err := mongoCursor.All(ctx, &recipesFromDatabase); err != nil {
+		return nil, "", false, app.LogError("GetRecipesPage", err)
+	}
+
+	hasMore := int64(len(recipesFromDatabase)) > limit
+	if hasMore {
+		recipesFromDatabase = recipesFromDatabase[:limit]
+	}
+
+	items, err := app.GetAllItems()
+	if err != nil {
+		return nil, "", false, app.LogError("GetRecipesPage", err)
+	}
+	for i := range recipesFromDatabase {
+		recipesFromDatabase[i].MapItemIdsToItem(items)
+	}
+
+	var nextCursor string
+	if hasMore && len(recipesFromDatabase) > 0 {
+		last := recipesFromDatabase[len(recipesFromDatabase)-1]
+		nextCursor = EncodeCursor(last.ID, last.Name)
+	}
+
+	return recipesFromDatabase, nextCursor, hasMore, nil
+}
+
+// getAllRecipesIncludingPrivate gets every non-deleted recipe regardless of owner,
+// for use by internal maintenance routines that operate on the whole collection
+func (app *TasteBuddyApp) getAllRecipesIncludingPrivate() ([]Recipe, error) {
+	return app.getRecipesByFilter(bson.M{"deleted": bson.M{"$ne": true}})
+}
+
+// getRecipesByFilter gets all recipes from database matching filter and maps their items
+func (app *TasteBuddyApp) getRecipesByFilter(filter bson.M) ([]Recipe, error) {
 	ctx := DefaultContext()
 
-	// get all recipes from database that are not deleted
-	cursor, err := app.GetRecipesCollection().Find(ctx, bson.M{"deleted": bson.M{"$ne": true}})
+	cursor, err := app.GetRecipesCollection().Find(ctx, filter)
 	if err != nil {
 		return []Recipe{}, app.LogError("GetAllRecipes", err)
 	}
@@ -338,7 +508,7 @@ func (app *TasteBuddyApp) GetRecipesByItemNames(splitItemIds []string) ([]Recipe
 	var recipesMap = make(map[string]Recipe)
 
 	// get all recipes from database
-	recipes, err := app.GetAllRecipes()
+	recipes, err := app.GetAllRecipes(primitive.NilObjectID)
 	if err != nil {
 		return []Recipe{}, app.LogError("GetRecipesByItemNames", err)
 	}
@@ -397,7 +567,7 @@ func (app *TasteBuddyApp) CleanUpItemsInRecipes() error {
 	}
 
 	// get recipes
-	recipes, err = app.GetAllRecipes()
+	recipes, err = app.getAllRecipesIncludingPrivate()
 	if err != nil {
 		return app.LogError("CleanUpItemsInRecipes", err)
 	}
@@ -434,7 +604,7 @@ func (app *TasteBuddyApp) CleanUpItemsInRecipes() error {
 // CleanUpUnusedAttributesInRecipes marshals and unmarshal all recipes and
 // tries to remove all unused attributes
 func (app *TasteBuddyApp) CleanUpUnusedAttributesInRecipes() error {
-	recipes, err := app.GetAllRecipes()
+	recipes, err := app.getAllRecipesIncludingPrivate()
 	if err != nil {
 		return app.LogError("CleanUpUnusedAttributesInRecipes", err)
 	}
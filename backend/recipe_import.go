@@ -0,0 +1,507 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// knownIngredientUnits are the units recognized by parseIngredientLine. Anything
+// else found in the unit position is treated as part of the item name instead
+var knownIngredientUnits = map[string]bool{
+	"g": true, "kg": true, "ml": true, "l": true,
+	"cup": true, "cups": true, "tbsp": true, "tsp": true,
+	"oz": true, "lb": true, "pinch": true,
+	"clove": true, "cloves": true, "slice": true, "slices": true,
+	"piece": true, "pieces": true,
+}
+
+// ingredientLineRegex captures a leading amount (integer, decimal or fraction,
+// including mixed fractions like "1 1/2"), an optional unit, and the rest of
+// the line as the item name
+var ingredientLineRegex = regexp.MustCompile(`^\s*(\d+\s\d+/\d+|\d+/\d+|\d+(?:\.\d+)?)\s*([A-Za-z]+)?\s+(.+?)\s*$`)
+
+// recipeImportError reports why a recipe could not be parsed out of a page,
+// so HandleImportRecipe can return a 422 with a parse-error report
+type recipeImportError struct {
+	Url    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+func (e *recipeImportError) Error() string {
+	return fmt.Sprintf("failed to import recipe from %s: %s", e.Url, e.Reason)
+}
+
+// HandleImportRecipe gets called by router
+// Fetches the given url, parses a schema.org Recipe out of it, and saves it
+func (server *TasteBuddyServer) HandleImportRecipe(context *gin.Context) {
+	server.LogContextHandle(context, "HandleImportRecipe", "Trying to import recipe")
+
+	var importRequest struct {
+		Url string `json:"url" binding:"required"`
+	}
+	if err := context.BindJSON(&importRequest); err != nil {
+		server.LogError("HandleImportRecipe", err)
+		BadRequestError(context, "Invalid import request")
+		return
+	}
+
+	newRecipe, err := server.ImportRecipeFromURL(importRequest.Url)
+	if err != nil {
+		server.LogError("HandleImportRecipe + "+importRequest.Url, err)
+		var parseErr *recipeImportError
+		if errors.As(err, &parseErr) {
+			UnprocessableEntityError(context, parseErr.Error())
+			return
+		}
+		ServerError(context, true)
+		return
+	}
+
+	if userId, ok := GetUserIdFromContext(context); ok {
+		newRecipe.OwnerID = userId
+	}
+
+	recipeId, err := server.AddOrUpdateRecipe(newRecipe)
+	if err != nil {
+		server.LogError("HandleImportRecipe + "+importRequest.Url, err)
+		ServerError(context, true)
+		return
+	}
+	server.LogContextHandle(context, "HandleImportRecipe", "Imported recipe "+newRecipe.Name+" ("+recipeId.Hex()+")")
+	Success(context, "Saved recipe "+recipeId.Hex())
+}
+
+// ImportRecipeFromURL fetches url and builds a Recipe from the schema.org Recipe
+// data embedded in the page, preferring JSON-LD and falling back to microdata
+func (app *TasteBuddyApp) ImportRecipeFromURL(url string) (Recipe, error) {
+	html, err := fetchUrl(url)
+	if err != nil {
+		return Recipe{}, app.LogError("ImportRecipeFromURL + "+url, err)
+	}
+
+	schemaRecipe, err := extractJSONLDRecipe(html)
+	if err != nil {
+		schemaRecipe, err = extractMicrodataRecipe(html)
+	}
+	if err != nil {
+		return Recipe{}, &recipeImportError{Url: url, Reason: err.Error()}
+	}
+
+	return app.schemaOrgRecipeToRecipe(schemaRecipe, url)
+}
+
+// fetchUrlMaxRedirects caps how many redirects fetchUrl follows, to bound an SSRF
+// attempt that chains redirects rather than giving a disallowed address directly
+const fetchUrlMaxRedirects = 5
+
+// disallowedFetchRanges are loopback, private, link-local and other non-public
+// IP ranges fetchUrl refuses to connect to, so the recipe importer can't be used
+// to make the server probe internal services or cloud metadata endpoints (SSRF)
+var disallowedFetchRanges = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// validateFetchURL rejects any URL that isn't plain http(s), or whose hostname
+// resolves to a disallowed IP range
+func validateFetchURL(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("unsupported URL scheme " + parsed.Scheme)
+	}
+
+	ips, err := net.LookupIP(parsed.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		for _, disallowed := range disallowedFetchRanges {
+			if disallowed.Contains(ip) {
+				return errors.New("refusing to fetch from a private or reserved address")
+			}
+		}
+	}
+	return nil
+}
+
+// fetchUrl gets the raw HTML body of url, refusing to fetch from or be
+// redirected to a private/reserved address
+func fetchUrl(rawUrl string) (string, error) {
+	if err := validateFetchURL(rawUrl); err != nil {
+		return "", err
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= fetchUrlMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return validateFetchURL(req.URL.String())
+		},
+	}
+
+	response, err := client.Get(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// jsonLDScriptRegex finds <script type="application/ld+json"> blocks
+var jsonLDScriptRegex = regexp.MustCompile(`(?is)<script[^>]+type=["']application/ld\+json["'][^>]*>(.*?)</script>`)
+
+// extractJSONLDRecipe finds the first JSON-LD block describing a schema.org Recipe
+func extractJSONLDRecipe(html string) (map[string]interface{}, error) {
+	matches := jsonLDScriptRegex.FindAllStringSubmatch(html, -1)
+	if matches == nil {
+		return nil, errors.New("no JSON-LD blocks found")
+	}
+
+	for _, match := range matches {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(match[1]), &parsed); err != nil {
+			continue
+		}
+
+		if recipe, ok := findJSONLDRecipe(parsed); ok {
+			return recipe, nil
+		}
+	}
+
+	return nil, errors.New("no JSON-LD block contains a Recipe")
+}
+
+// findJSONLDRecipe walks parsed JSON-LD looking for an object whose @type is
+// (or includes) "Recipe", following @graph arrays used by some sites
+func findJSONLDRecipe(node interface{}) (map[string]interface{}, bool) {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if isRecipeType(value["@type"]) {
+			return value, true
+		}
+		if graph, ok := value["@graph"]; ok {
+			return findJSONLDRecipe(graph)
+		}
+	case []interface{}:
+		for _, item := range value {
+			if recipe, ok := findJSONLDRecipe(item); ok {
+				return recipe, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func isRecipeType(rawType interface{}) bool {
+	switch value := rawType.(type) {
+	case string:
+		return value == "Recipe"
+	case []interface{}:
+		for _, t := range value {
+			if s, ok := t.(string); ok && s == "Recipe" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// microdataTagStripRegex strips HTML tags out of an itemprop's captured text content
+var microdataTagStripRegex = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// extractMicrodataRecipe falls back to scraping itemprop attributes when no
+// JSON-LD Recipe is present on the page
+func extractMicrodataRecipe(html string) (map[string]interface{}, error) {
+	name := microdataProp(html, "name")
+	if name == "" {
+		return nil, errors.New("no microdata Recipe found")
+	}
+
+	recipe := map[string]interface{}{
+		"name":               name,
+		"description":        microdataProp(html, "description"),
+		"image":              microdataProp(html, "image"),
+		"totalTime":          microdataProp(html, "totalTime"),
+		"recipeCategory":     microdataProp(html, "recipeCategory"),
+		"recipeIngredient":   microdataPropAll(html, "recipeIngredient"),
+		"recipeInstructions": microdataPropAll(html, "recipeInstructions"),
+	}
+	if author := microdataProp(html, "author"); author != "" {
+		recipe["author"] = author
+	}
+
+	return recipe, nil
+}
+
+func microdataProp(html string, prop string) string {
+	all := microdataPropAll(html, prop)
+	if len(all) == 0 {
+		return ""
+	}
+	return all[0]
+}
+
+func microdataPropAll(html string, prop string) []string {
+	re := regexp.MustCompile(fmt.Sprintf(`(?is)itemprop=["']%s["'][^>]*>(.*?)<`, regexp.QuoteMeta(prop)))
+	matches := re.FindAllStringSubmatch(html, -1)
+	values := make([]string, 0, len(matches))
+	for _, match := range matches {
+		text := strings.TrimSpace(microdataTagStripRegex.ReplaceAllString(match[1], ""))
+		if text != "" {
+			values = append(values, text)
+		}
+	}
+	return values
+}
+
+// schemaOrgRecipeToRecipe maps a parsed schema.org Recipe object onto our Recipe type
+func (app *TasteBuddyApp) schemaOrgRecipeToRecipe(schemaRecipe map[string]interface{}, sourceUrl string) (Recipe, error) {
+	recipe := Recipe{
+		Name:        stringField(schemaRecipe["name"]),
+		Author:      authorName(schemaRecipe["author"]),
+		Description: stringField(schemaRecipe["description"]),
+	}
+	if recipe.Name == "" {
+		return Recipe{}, &recipeImportError{Url: sourceUrl, Reason: "missing recipe name"}
+	}
+
+	recipe.Props.Url = sourceUrl
+	recipe.Props.ImgUrl = stringField(schemaRecipe["image"])
+	recipe.Props.Tags = stringListField(schemaRecipe["recipeCategory"], schemaRecipe["keywords"])
+	if minutes, err := parseISO8601Duration(stringField(schemaRecipe["totalTime"])); err == nil {
+		recipe.Props.Duration = minutes
+	}
+
+	ingredientLines := stringListField(schemaRecipe["recipeIngredient"])
+	for _, line := range ingredientLines {
+		stepItem, err := app.parseIngredientLine(line)
+		if err != nil {
+			app.LogWarning("schemaOrgRecipeToRecipe + "+recipe.Name, "Could not parse ingredient \""+line+"\": "+err.Error())
+			continue
+		}
+		recipe.Items = append(recipe.Items, stepItem)
+	}
+
+	for _, instruction := range instructionSteps(schemaRecipe["recipeInstructions"]) {
+		recipe.Steps = append(recipe.Steps, StepFromDescription(instruction))
+	}
+	if len(recipe.Steps) == 0 {
+		return Recipe{}, &recipeImportError{Url: sourceUrl, Reason: "missing recipe instructions"}
+	}
+
+	return recipe, nil
+}
+
+// parseIngredientLine parses ingredient lines such as "200 g flour",
+// "1 1/2 cups milk" or "2 eggs" into a StepItem, deduping the Item via AddOrUpdateItem
+func (app *TasteBuddyApp) parseIngredientLine(line string) (StepItem, error) {
+	matches := ingredientLineRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return StepItem{}, errors.New("does not match amount/unit/name pattern")
+	}
+
+	amount, err := parseIngredientAmount(matches[1])
+	if err != nil {
+		return StepItem{}, err
+	}
+
+	unit := strings.ToLower(matches[2])
+	name := matches[3]
+	if !knownIngredientUnits[unit] {
+		// not a recognized unit: fold it back into the item name
+		if unit != "" {
+			name = unit + " " + name
+		}
+		unit = ""
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return StepItem{}, errors.New("missing item name")
+	}
+
+	itemId, err := app.AddOrUpdateItem(Item{Name: name})
+	if err != nil {
+		return StepItem{}, app.LogError("parseIngredientLine + "+name, err)
+	}
+
+	return StepItem{
+		ItemID: itemId,
+		Amount: int(math.Round(amount)),
+		Unit:   unit,
+		Item:   Item{Name: name},
+	}, nil
+}
+
+// parseIngredientAmount parses "200", "1.5", "1/2" and mixed fractions like "1 1/2"
+func parseIngredientAmount(token string) (float64, error) {
+	if strings.Contains(token, " ") {
+		parts := strings.SplitN(token, " ", 2)
+		whole, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return 0, err
+		}
+		fraction, err := parseFraction(parts[1])
+		if err != nil {
+			return 0, err
+		}
+		return whole + fraction, nil
+	}
+	if strings.Contains(token, "/") {
+		return parseFraction(token)
+	}
+	return strconv.ParseFloat(token, 64)
+}
+
+func parseFraction(token string) (float64, error) {
+	parts := strings.SplitN(token, "/", 2)
+	if len(parts) != 2 {
+		return strconv.ParseFloat(token, 64)
+	}
+	numerator, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	denominator, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || denominator == 0 {
+		return 0, errors.New("invalid fraction " + token)
+	}
+	return numerator / denominator, nil
+}
+
+// iso8601DurationRegex matches the subset of ISO-8601 durations schema.org uses
+// for totalTime, e.g. "PT1H30M"
+var iso8601DurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?T?(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// parseISO8601Duration converts an ISO-8601 duration into whole minutes
+func parseISO8601Duration(duration string) (int, error) {
+	matches := iso8601DurationRegex.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, errors.New("invalid ISO-8601 duration " + duration)
+	}
+
+	days, _ := strconv.Atoi(matches[1])
+	hours, _ := strconv.Atoi(matches[2])
+	minutes, _ := strconv.Atoi(matches[3])
+	seconds, _ := strconv.Atoi(matches[4])
+
+	return days*24*60 + hours*60 + minutes + seconds/60, nil
+}
+
+// stringField coerces a JSON-decoded value that is either a string, or an
+// object with a "name"/"url" field (as schema.org allows for author/image), to a string
+func stringField(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if name, ok := v["name"].(string); ok {
+			return name
+		}
+		if url, ok := v["url"].(string); ok {
+			return url
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			return stringField(v[0])
+		}
+	}
+	return ""
+}
+
+func authorName(value interface{}) string {
+	return stringField(value)
+}
+
+// stringListField flattens one or more JSON-decoded values (strings, comma-separated
+// strings, or string arrays) into a single list of strings
+func stringListField(values ...interface{}) []string {
+	var result []string
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+			for _, part := range strings.Split(v, ",") {
+				if trimmed := strings.TrimSpace(part); trimmed != "" {
+					result = append(result, trimmed)
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				result = append(result, stringField(item))
+			}
+		case []string:
+			result = append(result, v...)
+		}
+	}
+	return result
+}
+
+// instructionSteps flattens schema.org recipeInstructions, which may be a plain
+// string, a list of strings, or a list of HowToStep objects with a "text" field
+func instructionSteps(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var steps []string
+		for _, item := range v {
+			switch step := item.(type) {
+			case string:
+				steps = append(steps, step)
+			case map[string]interface{}:
+				if text, ok := step["text"].(string); ok {
+					steps = append(steps, text)
+				}
+			}
+		}
+		return steps
+	}
+	return nil
+}
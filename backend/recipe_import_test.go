@@ -0,0 +1,137 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import "testing"
+
+func TestParseIngredientAmount(t *testing.T) {
+	tests := []struct {
+		token   string
+		want    float64
+		wantErr bool
+	}{
+		{"200", 200, false},
+		{"1.5", 1.5, false},
+		{"1/2", 0.5, false},
+		{"1 1/2", 1.5, false},
+		{"", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseIngredientAmount(test.token)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseIngredientAmount(%q) = %v, want error", test.token, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseIngredientAmount(%q) returned unexpected error: %v", test.token, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseIngredientAmount(%q) = %v, want %v", test.token, got, test.want)
+		}
+	}
+}
+
+func TestParseFraction(t *testing.T) {
+	tests := []struct {
+		token   string
+		want    float64
+		wantErr bool
+	}{
+		{"1/2", 0.5, false},
+		{"3/4", 0.75, false},
+		{"1/0", 0, true},
+		{"1/a", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseFraction(test.token)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseFraction(%q) = %v, want error", test.token, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFraction(%q) returned unexpected error: %v", test.token, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseFraction(%q) = %v, want %v", test.token, got, test.want)
+		}
+	}
+}
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     int
+		wantErr  bool
+	}{
+		{"PT1H30M", 90, false},
+		{"PT45M", 45, false},
+		{"PT1H", 60, false},
+		{"P0DT30M", 30, false},
+		{"not a duration", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseISO8601Duration(test.duration)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseISO8601Duration(%q) = %v, want error", test.duration, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseISO8601Duration(%q) returned unexpected error: %v", test.duration, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseISO8601Duration(%q) = %v, want %v", test.duration, got, test.want)
+		}
+	}
+}
+
+func TestStringListField(t *testing.T) {
+	got := stringListField("a, b", []interface{}{"c", "d"})
+	want := []string{"a", "b", "c", "d"}
+	if len(got) != len(want) {
+		t.Fatalf("stringListField() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stringListField()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestValidateFetchURLRejectsDisallowedSchemes(t *testing.T) {
+	tests := []string{"file:///etc/passwd", "ftp://example.com/recipe", "gopher://example.com"}
+	for _, rawUrl := range tests {
+		if err := validateFetchURL(rawUrl); err == nil {
+			t.Errorf("validateFetchURL(%q) = nil, want an error", rawUrl)
+		}
+	}
+}
+
+func TestInstructionSteps(t *testing.T) {
+	got := instructionSteps([]interface{}{
+		"Preheat the oven",
+		map[string]interface{}{"text": "Mix the ingredients"},
+	})
+	want := []string{"Preheat the oven", "Mix the ingredients"}
+	if len(got) != len(want) {
+		t.Fatalf("instructionSteps() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("instructionSteps()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
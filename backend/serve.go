@@ -15,18 +15,28 @@ var serveCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetString("port")
 		logLevel, _ := cmd.Flags().GetString("loglevel")
+		logFormat, _ := cmd.Flags().GetString("log-format")
 		mode, _ := cmd.Flags().GetString("mode")
-		// configFilePath, _ := cmd.Flags().GetString("config")
+		configFilePath, _ := cmd.Flags().GetString("config")
 
 		// Create the TasteBuddyApp
 		app := TasteBuddyAppFactory()
-		app.SetLogger(logLevel)
+		app.SetLogger(logLevel, logFormat)
+		if configFilePath != "" {
+			config, err := LoadConfig(configFilePath)
+			if err != nil {
+				app.LogError("serve", err)
+			} else {
+				app.SetConfig(config)
+			}
+		}
 		app.Default()
+		app.RegisterDefaultDiscountProviders()
 
 		// Run go routines
 		// cities := []string{"Tuebingen", "Stuttgart", "Reutlingen"}
 		// app.GoRoutineSaveMarketsToDB(cities)
-		// app.GoRoutineSaveDiscountsToDB(cities)
+		GoRoutineSaveDiscountsToDB(app)
 
 		// Create the server
 		TasteBuddyServerFactory(app).
@@ -50,6 +60,7 @@ func init() {
 	// is called directly, e.g.:
 	serveCmd.Flags().StringP("port", "p", "8081", "Set the port to use")
 	serveCmd.Flags().StringP("loglevel", "l", "default", "Set the log level")
+	serveCmd.Flags().StringP("log-format", "", "json", "Set the log format (json|console)")
 	serveCmd.Flags().StringP("mode", "m", "prod", "Set the mode")
 	serveCmd.Flags().StringP("config", "c", "", "Set the config file")
 }
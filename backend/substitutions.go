@@ -0,0 +1,184 @@
+/*
+Copyright © 2023 JOSEF MUELLER
+*/
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// substitutionScorePenalty is applied to the match score when a recipe item is
+// satisfied by a substitute of an item the user has, instead of the item itself
+const substitutionScorePenalty = 0.8
+
+// Substitution is a bidirectional link between two interchangeable items,
+// e.g. butter and margarine
+type Substitution struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ItemID       primitive.ObjectID `json:"itemId" bson:"itemId" binding:"required"`
+	SubstituteID primitive.ObjectID `json:"substituteId" bson:"substituteId" binding:"required"`
+}
+
+// RecipeMatch is a Recipe ranked against the items a user has, scored by
+// matched_items / total_items_in_recipe
+type RecipeMatch struct {
+	Recipe           Recipe  `json:"recipe"`
+	Score            float64 `json:"score"`
+	MissingItems     []Item  `json:"missingItems"`
+	SubstitutedItems []Item  `json:"substitutedItems"`
+}
+
+// HandleMatchRecipesByItemIds gets called by router
+// Calls MatchRecipesByItemIds and handles the context
+func (server *TasteBuddyServer) HandleMatchRecipesByItemIds(context *gin.Context) {
+	itemIds := context.Param("itemIds")
+	splitItemIds := strings.Split(itemIds, ",")
+
+	minScore := 0.0
+	if minParam := context.Query("min"); minParam != "" {
+		parsedMin, err := strconv.ParseFloat(minParam, 64)
+		if err != nil {
+			server.LogError("HandleMatchRecipesByItemIds", err)
+			BadRequestError(context, "Invalid min")
+			return
+		}
+		minScore = parsedMin
+	}
+	allowSubstitutes := context.Query("substitutes") == "true"
+
+	userId, _ := GetUserIdFromContext(context)
+	matches, err := server.MatchRecipesByItemIds(userId, splitItemIds, minScore, allowSubstitutes)
+	if err != nil {
+		server.LogError("HandleMatchRecipesByItemIds", err)
+		ServerError(context, true)
+		return
+	}
+	Success(context, matches)
+}
+
+// MatchRecipesByItemIds ranks every recipe visible to userId (public, plus userId's own
+// private recipes) by how much of it the user can make with userItemIds, as
+// matched_items / total_items_in_recipe. If allowSubstitutes is true, a recipe item can
+// also be satisfied by a substitute of an item the user has, at substitutionScorePenalty
+// of the score. Only matches scoring >= minScore are returned
+func (app *TasteBuddyApp) MatchRecipesByItemIds(userId primitive.ObjectID, userItemIds []string, minScore float64, allowSubstitutes bool) ([]RecipeMatch, error) {
+	recipes, err := app.GetAllRecipes(userId)
+	if err != nil {
+		return nil, app.LogError("MatchRecipesByItemIds", err)
+	}
+
+	userItemIdSet := make(map[string]bool, len(userItemIds))
+	for _, itemId := range userItemIds {
+		userItemIdSet[itemId] = true
+	}
+
+	var matches []RecipeMatch
+	for _, recipe := range recipes {
+		recipeItems := recipe.ExtractItems()
+		if len(recipeItems) == 0 {
+			continue
+		}
+
+		var score float64
+		var missingItems []Item
+		var substitutedItems []Item
+		for _, recipeItem := range recipeItems {
+			switch {
+			case userItemIdSet[recipeItem.ID.Hex()]:
+				score++
+			case allowSubstitutes && app.userHasSubstituteForItem(recipeItem.ID, userItemIdSet):
+				score += substitutionScorePenalty
+				substitutedItems = append(substitutedItems, recipeItem)
+			default:
+				missingItems = append(missingItems, recipeItem)
+			}
+		}
+		score = score / float64(len(recipeItems))
+
+		if score < minScore {
+			continue
+		}
+		matches = append(matches, RecipeMatch{
+			Recipe:           recipe,
+			Score:            score,
+			MissingItems:     missingItems,
+			SubstitutedItems: substitutedItems,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches, nil
+}
+
+// userHasSubstituteForItem checks whether any registered substitute of itemId is in userItemIdSet
+func (app *TasteBuddyApp) userHasSubstituteForItem(itemId primitive.ObjectID, userItemIdSet map[string]bool) bool {
+	substituteIds, err := app.GetSubstituteIdsForItem(itemId)
+	if err != nil {
+		return false
+	}
+	for _, substituteId := range substituteIds {
+		if userItemIdSet[substituteId.Hex()] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSubstitutionsCollection gets substitutions collection from database
+func (app *TasteBuddyApp) GetSubstitutionsCollection() *mongo.Collection {
+	return app.client.Database("tastebuddy").Collection("substitutions")
+}
+
+// GetSubstituteIdsForItem gets every item id that can substitute for itemId.
+// Substitutions are stored once but apply in both directions
+func (app *TasteBuddyApp) GetSubstituteIdsForItem(itemId primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ctx := DefaultContext()
+
+	filter := bson.M{"$or": []bson.M{
+		{"itemId": itemId},
+		{"substituteId": itemId},
+	}}
+	cursor, err := app.GetSubstitutionsCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, app.LogError("GetSubstituteIdsForItem", err)
+	}
+
+	var substitutions []Substitution
+	if err := cursor.All(ctx, &substitutions); err != nil {
+		return nil, app.LogError("GetSubstituteIdsForItem", err)
+	}
+
+	var substituteIds []primitive.ObjectID
+	for _, substitution := range substitutions {
+		if substitution.ItemID == itemId {
+			substituteIds = append(substituteIds, substitution.SubstituteID)
+		} else {
+			substituteIds = append(substituteIds, substitution.ItemID)
+		}
+	}
+	return substituteIds, nil
+}
+
+// AddSubstitution registers itemId and substituteId as interchangeable
+func (app *TasteBuddyApp) AddSubstitution(itemId primitive.ObjectID, substituteId primitive.ObjectID) error {
+	ctx := DefaultContext()
+
+	_, err := app.GetSubstitutionsCollection().InsertOne(ctx, Substitution{
+		ItemID:       itemId,
+		SubstituteID: substituteId,
+	})
+	if err != nil {
+		return app.LogError("AddSubstitution", err)
+	}
+	return nil
+}